@@ -4,66 +4,394 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
 )
 
 var _ json.Unmarshaler = (*Exclude)(nil)
 
-// Exclude parses the exclude json configuration. Files and directories that are
-// matched by any pattern in the json are excluded from the analysis.
+// Pattern is a single compiled exclude pattern.
+type Pattern struct {
+	comment         string
+	negate          bool     // pattern began with "!": a later match re-includes the path
+	anchored        bool     // pattern began with "/": only matches relative to base
+	dirOnly         bool     // pattern ended with "/": only matches directories
+	caseInsensitive bool     // pattern came from an "iexclude" source
+	base            string   // absolute directory the pattern is relative to
+	segments        []string // pattern split on "/", with anchoring and trailing slash stripped
+}
+
+// Exclude parses the exclude json configuration. Files and directories that
+// are matched by any pattern in the json are excluded from the analysis.
+//
+// Patterns follow gitignore syntax: "**" matches any number of path
+// segments, "?" and "[...]" behave as in filepath.Match, a leading "/"
+// anchors the pattern to the directory of the config file, a trailing "/"
+// restricts the pattern to directories, and a leading "!" negates a
+// pattern, re-including a path that an earlier pattern excluded. Patterns
+// are evaluated in the order they appear in the json, so later patterns
+// take precedence over earlier ones. A pattern with no "/" in it, such as
+// ".git", is matched against every path component rather than just the
+// last one, so it excludes any directory with that name and everything
+// beneath it, wherever it occurs.
 //
 // This is a sample json:
 //  {
-//    "testdata/testdata.go": "Tracked in issue #42"
+//    "testdata/testdata.go": "Tracked in issue #42",
+//    "testdata/generated/**": "Generated code, never worth fixing",
+//    "!testdata/generated/keep.go": "Hand-written, don't skip this one"
 //  }
 //
-// The keys of the config are the patterns to match. They can either be relative
-// or absolute path patterns. The value serves as comment to indicate why the
-// ignore is necessary. It is good practice to attach the github issue number
-// that tracks the reason.
-type Exclude map[string]string
+// The keys of the config are the patterns to match. The value serves as
+// comment to indicate why the ignore is necessary. It is good practice to
+// attach the github issue number that tracks the reason.
+//
+// The config may instead be an object describing multiple sources of
+// patterns, case-insensitive patterns, and exclude-if-present markers:
+//  {
+//    "patterns": {"testdata/testdata.go": "Tracked in issue #42"},
+//    "case_insensitive": ["**/readme*"],
+//    "exclude_if_present": ["CACHEDIR.TAG", ".ineffassign-skip"]
+//  }
+// "case_insensitive" patterns match regardless of case. A directory
+// containing any of the "exclude_if_present" marker files is excluded
+// wholesale.
+type Exclude struct {
+	patterns         []Pattern
+	excludeIfPresent []string
+}
 
-// LoadFromFile loads the exclude configuration from the specified file.
+// LoadFromFile loads the exclude configuration from the specified file and
+// merges it into e, so LoadFromFile may be called repeatedly with multiple
+// files. Anchored patterns (those beginning with "/") are resolved against
+// the directory containing file, not the process's working directory.
 func (e *Exclude) LoadFromFile(file string) error {
 	b, err := ioutil.ReadFile(file)
 	if err != nil {
 		return err
 	}
-	return json.Unmarshal(b, e)
+	base, err := filepath.Abs(filepath.Dir(file))
+	if err != nil {
+		return err
+	}
+	var loaded Exclude
+	if err := json.Unmarshal(b, &loaded); err != nil {
+		return err
+	}
+	for i := range loaded.patterns {
+		loaded.patterns[i].base = base
+	}
+	e.patterns = append(e.patterns, loaded.patterns...)
+	e.excludeIfPresent = append(e.excludeIfPresent, loaded.excludeIfPresent...)
+	return nil
 }
 
-// UnmarshalJSON parses the config, checks whether all pattern are
-// well-formed, and transforms relative patterns to absolute patterns.
+// UnmarshalJSON parses the config, checks whether all patterns are
+// well-formed, and compiles each one into a matcher. Object key order is
+// preserved so that negated patterns are evaluated after the patterns they
+// re-include. It accepts either the flat, backward-compatible
+// pattern-to-comment form or the multi-source object form, sniffing the
+// shape from the top-level keys present.
 func (e *Exclude) UnmarshalJSON(b []byte) error {
-	var m map[string]string
-	if err := json.Unmarshal(b, &m); err != nil {
+	base, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("unable to determine working directory: err=%s", err)
+	}
+
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(b, &probe); err != nil {
+		return err
+	}
+	_, hasPatterns := probe["patterns"]
+	_, hasCaseInsensitive := probe["case_insensitive"]
+	_, hasExcludeIfPresent := probe["exclude_if_present"]
+	if hasPatterns || hasCaseInsensitive || hasExcludeIfPresent {
+		return e.unmarshalStructured(probe, base)
+	}
+
+	keys, vals, err := decodeOrderedStringMap(b)
+	if err != nil {
 		return err
 	}
-	*e = make(map[string]string)
-	for pattern := range m {
-		if _, err := filepath.Glob(pattern); err != nil {
-			return fmt.Errorf("invalid pattern: pattern=%s err=%s", pattern, err)
+	patterns := make([]Pattern, 0, len(keys))
+	for i, raw := range keys {
+		p, err := compilePattern(raw, vals[i], base, false)
+		if err != nil {
+			return err
 		}
-		absPattern, err := filepath.Abs(pattern)
+		patterns = append(patterns, p)
+	}
+	e.patterns = patterns
+	return nil
+}
+
+// unmarshalStructured decodes the "patterns", "case_insensitive" and
+// "exclude_if_present" sections of the multi-source object form.
+func (e *Exclude) unmarshalStructured(probe map[string]json.RawMessage, base string) error {
+	var patterns []Pattern
+	if raw, ok := probe["patterns"]; ok {
+		keys, vals, err := decodeOrderedStringMap(raw)
 		if err != nil {
-			return fmt.Errorf("unable to get absolute pattern: pattern=%s err=%err", pattern, err)
+			return err
+		}
+		for i, k := range keys {
+			p, err := compilePattern(k, vals[i], base, false)
+			if err != nil {
+				return err
+			}
+			patterns = append(patterns, p)
+		}
+	}
+	if raw, ok := probe["case_insensitive"]; ok {
+		var list []string
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return fmt.Errorf("invalid exclude config: case_insensitive: %s", err)
+		}
+		for _, raw := range list {
+			p, err := compilePattern(raw, "", base, true)
+			if err != nil {
+				return err
+			}
+			patterns = append(patterns, p)
 		}
-		(*e)[absPattern] = m[pattern]
+	}
+	e.patterns = patterns
+
+	if raw, ok := probe["exclude_if_present"]; ok {
+		var markers []string
+		if err := json.Unmarshal(raw, &markers); err != nil {
+			return fmt.Errorf("invalid exclude config: exclude_if_present: %s", err)
+		}
+		e.excludeIfPresent = markers
 	}
 	return nil
 }
 
+// decodeOrderedStringMap decodes a json object of string values, returning
+// its keys and values in the order they appear in b. encoding/json decodes
+// objects into Go maps, which do not preserve key order, so we walk the
+// token stream ourselves.
+func decodeOrderedStringMap(b []byte) (keys, vals []string, err error) {
+	dec := json.NewDecoder(strings.NewReader(string(b)))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, nil, err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return nil, nil, fmt.Errorf("invalid exclude config: expected a json object")
+	}
+	for dec.More() {
+		keyTok, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key, ok := keyTok.(string)
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid exclude config: pattern keys must be strings")
+		}
+		var val string
+		if err := dec.Decode(&val); err != nil {
+			return nil, nil, fmt.Errorf("invalid exclude config: pattern=%s err=%s", key, err)
+		}
+		keys = append(keys, key)
+		vals = append(vals, val)
+	}
+	return keys, vals, nil
+}
+
+// compilePattern parses the gitignore-style syntax of raw and compiles it
+// into a matcher anchored at base. caseInsensitive marks a pattern sourced
+// from an "iexclude"-style list, matching regardless of case.
+func compilePattern(raw, comment, base string, caseInsensitive bool) (Pattern, error) {
+	p := Pattern{comment: comment, base: base, caseInsensitive: caseInsensitive}
+
+	s := raw
+	if strings.HasPrefix(s, "!") {
+		p.negate = true
+		s = s[1:]
+	}
+	if strings.HasPrefix(s, "/") {
+		p.anchored = true
+		s = s[1:]
+	}
+	if strings.HasSuffix(s, "/") {
+		p.dirOnly = true
+		s = strings.TrimSuffix(s, "/")
+	}
+	if s == "" {
+		return Pattern{}, fmt.Errorf("invalid pattern: pattern=%s err=empty pattern", raw)
+	}
+
+	p.segments = strings.Split(s, "/")
+	for _, seg := range p.segments {
+		if seg == "**" {
+			continue
+		}
+		if _, err := filepath.Match(seg, ""); err != nil {
+			return Pattern{}, fmt.Errorf("invalid pattern: pattern=%s err=%s", raw, err)
+		}
+	}
+	return p, nil
+}
+
+// SkipDir is filepath.SkipDir, re-exported here for convenience. A caller
+// walking a tree with filepath.Walk should return it from their WalkFunc
+// as soon as Excluded reports a directory excluded, so filepath.Walk never
+// descends into it: that short-circuit is what makes a single basename
+// pattern like ".git" exclude an entire subtree without Excluded having to
+// be asked about every descendant.
+var SkipDir = filepath.SkipDir
+
 // Excluded indicates whether this path is excluded by the configuration.
+// Patterns are evaluated in order, so a negated pattern later in the list
+// re-includes a path an earlier pattern excluded. A path whose directory
+// contains one of the configured exclude-if-present marker files is always
+// excluded.
 func (e *Exclude) Excluded(path string) bool {
-	for pattern := range *e {
-		matched, err := filepath.Match(pattern, path)
-		if err != nil {
-			panic(fmt.Sprintf("Uncaught bad pattern"))
+	if e.excludedByMarker(path) {
+		return true
+	}
+	excluded := false
+	for _, p := range e.patterns {
+		if p.matches(path) {
+			excluded = !p.negate
+		}
+	}
+	return excluded
+}
+
+// excludedByMarker reports whether path, or any directory above it, holds
+// one of the exclude-if-present marker files, e.g. a CACHEDIR.TAG. A
+// marker excludes the directory it's in and everything below it, so a
+// marker several levels up still applies to a deeply nested path.
+func (e *Exclude) excludedByMarker(path string) bool {
+	if len(e.excludeIfPresent) == 0 {
+		return false
+	}
+	dir := path
+	if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+		dir = filepath.Dir(path)
+	}
+	for {
+		for _, marker := range e.excludeIfPresent {
+			if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+				return true
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
+
+// matches reports whether path is matched by p. A dirOnly pattern matches
+// not only the directory itself but anything underneath it: the pattern
+// only has to match a leading prefix of path's segments, the same way
+// excluding a directory in gitignore excludes its contents without every
+// file inside needing its own rule.
+func (p Pattern) matches(path string) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(p.base, absPath)
+	if err != nil {
+		return false
+	}
+	pathSegs := strings.Split(filepath.ToSlash(rel), "/")
+	if pathSegs[0] == ".." {
+		// path lies outside the directory p is scoped to, so p can't match
+		// it regardless of anchoring: a pattern loaded from one directory's
+		// ignore file must never reach into a sibling directory's files.
+		return false
+	}
+	if p.dirOnly {
+		// A dirOnly pattern never matches a file's own name, only the
+		// directories above it.
+		if fi, err := os.Stat(absPath); err == nil && !fi.IsDir() {
+			pathSegs = pathSegs[:len(pathSegs)-1]
 		}
-		if matched {
+	}
+
+	if p.anchored {
+		return p.matchSegments(pathSegs)
+	}
+	if p.isBasename() {
+		pat := p.segments[0]
+		if p.caseInsensitive {
+			pat = strings.ToLower(pat)
+		}
+		for _, seg := range pathSegs {
+			name := seg
+			if p.caseInsensitive {
+				name = strings.ToLower(name)
+			}
+			if ok, err := filepath.Match(pat, name); err == nil && ok {
+				return true
+			}
+		}
+		return false
+	}
+	for i := range pathSegs {
+		if p.matchSegments(pathSegs[i:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments reports whether p's segments match pathSegs. For an
+// ordinary pattern this means the whole of pathSegs; for a dirOnly
+// pattern it's enough to match some leading prefix of pathSegs, since
+// anything past that prefix lives inside the excluded directory.
+func (p Pattern) matchSegments(pathSegs []string) bool {
+	if !p.dirOnly {
+		return matchSegments(p.segments, pathSegs, p.caseInsensitive)
+	}
+	for k := 0; k <= len(pathSegs); k++ {
+		if matchSegments(p.segments, pathSegs[:k], p.caseInsensitive) {
 			return true
 		}
 	}
 	return false
+}
+
+// isBasename reports whether p is a slash-less pattern such as ".git",
+// which gitignore (and this package) matches against every path component
+// rather than only the final one: it excludes any path with a component
+// matching the pattern, anywhere in the tree.
+func (p Pattern) isBasename() bool {
+	return !p.anchored && len(p.segments) == 1 && p.segments[0] != "**"
+}
 
+// matchSegments reports whether pathSegs is matched by patSegs, where a
+// "**" segment in patSegs matches zero or more segments of pathSegs.
+func matchSegments(patSegs, pathSegs []string, caseInsensitive bool) bool {
+	if len(patSegs) == 0 {
+		return len(pathSegs) == 0
+	}
+	if patSegs[0] == "**" {
+		if matchSegments(patSegs[1:], pathSegs, caseInsensitive) {
+			return true
+		}
+		if len(pathSegs) == 0 {
+			return false
+		}
+		return matchSegments(patSegs, pathSegs[1:], caseInsensitive)
+	}
+	if len(pathSegs) == 0 {
+		return false
+	}
+	seg, name := patSegs[0], pathSegs[0]
+	if caseInsensitive {
+		seg, name = strings.ToLower(seg), strings.ToLower(name)
+	}
+	ok, err := filepath.Match(seg, name)
+	if err != nil || !ok {
+		return false
+	}
+	return matchSegments(patSegs[1:], pathSegs[1:], caseInsensitive)
 }
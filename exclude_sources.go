@@ -0,0 +1,22 @@
+package main
+
+import "path/filepath"
+
+// LoadPatternsFromFile merges the patterns in a plain, newline-delimited
+// pattern file into e: one pattern per line, blank lines and lines
+// starting with "#" ignored. Patterns are anchored to file's own
+// directory, the same way LoadFromFile anchors a JSON config.
+// LoadPatternsFromFile may be called repeatedly, alongside LoadFromFile, to
+// merge patterns from multiple sources.
+func (e *Exclude) LoadPatternsFromFile(file string) error {
+	base, err := filepath.Abs(filepath.Dir(file))
+	if err != nil {
+		return err
+	}
+	patterns, err := loadPatternFile(file, base)
+	if err != nil {
+		return err
+	}
+	e.patterns = append(e.patterns, patterns...)
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadPatternsFromFileMergesWithExistingPatterns(t *testing.T) {
+	dir := t.TempDir()
+	e := &Exclude{}
+	if err := e.LoadFromFile(writeExcludeConfig(t, dir, `{"vendor/**": "vendored code"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	patternFile := filepath.Join(dir, "extra.ignore")
+	if err := ioutil.WriteFile(patternFile, []byte("# comment\n\n*.log\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.LoadPatternsFromFile(patternFile); err != nil {
+		t.Fatal(err)
+	}
+
+	if !e.Excluded(filepath.Join(dir, "vendor", "x.go")) {
+		t.Errorf("expected the original pattern to still apply after merging")
+	}
+	if !e.Excluded(filepath.Join(dir, "debug.log")) {
+		t.Errorf("expected the merged pattern file's *.log to apply")
+	}
+}
+
+func TestExcludeIfPresentAppliesToDescendants(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "a", "b", "c")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	e := &Exclude{}
+	body := `{"exclude_if_present": ["CACHEDIR.TAG"]}`
+	if err := e.LoadFromFile(writeExcludeConfig(t, root, body)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "a", "CACHEDIR.TAG"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if !e.Excluded(filepath.Join(sub, "file.go")) {
+		t.Errorf("expected a marker two levels up to exclude a deeply nested file")
+	}
+	if e.Excluded(filepath.Join(root, "other", "file.go")) {
+		t.Errorf("a marker in a/ should not exclude an unrelated sibling directory")
+	}
+}
+
+func TestExcludeCaseInsensitive(t *testing.T) {
+	dir := t.TempDir()
+	e := &Exclude{}
+	body := `{"case_insensitive": ["README.md"]}`
+	if err := e.LoadFromFile(writeExcludeConfig(t, dir, body)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !e.Excluded(filepath.Join(dir, "readme.MD")) {
+		t.Errorf("expected a case_insensitive pattern to match regardless of case")
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeExcludeConfig(t *testing.T, dir, body string) string {
+	t.Helper()
+	file := filepath.Join(dir, "exclude.json")
+	if err := ioutil.WriteFile(file, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestExcludeGlobstar(t *testing.T) {
+	dir := t.TempDir()
+	e := &Exclude{}
+	if err := e.LoadFromFile(writeExcludeConfig(t, dir, `{"vendor/**": "vendored code"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !e.Excluded(filepath.Join(dir, "vendor", "pkg", "nested", "file.go")) {
+		t.Errorf("expected vendor/** to exclude a deeply nested file")
+	}
+	if e.Excluded(filepath.Join(dir, "main.go")) {
+		t.Errorf("expected main.go not to be excluded")
+	}
+}
+
+func TestExcludeNegationOrder(t *testing.T) {
+	dir := t.TempDir()
+	e := &Exclude{}
+	body := `{
+		"generated/**": "generated code",
+		"!generated/keep.go": "hand-written, don't skip"
+	}`
+	if err := e.LoadFromFile(writeExcludeConfig(t, dir, body)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !e.Excluded(filepath.Join(dir, "generated", "other.go")) {
+		t.Errorf("expected generated/other.go to be excluded")
+	}
+	if e.Excluded(filepath.Join(dir, "generated", "keep.go")) {
+		t.Errorf("expected the later negated pattern to re-include generated/keep.go")
+	}
+}
+
+func TestExcludeAnchoredToConfigDir(t *testing.T) {
+	configDir := t.TempDir()
+	otherDir := t.TempDir()
+	e := &Exclude{}
+	if err := e.LoadFromFile(writeExcludeConfig(t, configDir, `{"/a/b/file.go": "tracked"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !e.Excluded(filepath.Join(configDir, "a", "b", "file.go")) {
+		t.Errorf("expected the anchored pattern to match relative to the config file's directory")
+	}
+	if e.Excluded(filepath.Join(otherDir, "a", "b", "file.go")) {
+		t.Errorf("anchored pattern should not match a/b/file.go under an unrelated directory")
+	}
+}
+
+func TestExcludeDirOnlyMatchesContents(t *testing.T) {
+	dir := t.TempDir()
+	e := &Exclude{}
+	if err := e.LoadFromFile(writeExcludeConfig(t, dir, `{"vendor/": "vendored code"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !e.Excluded(filepath.Join(dir, "vendor")) {
+		t.Errorf("expected the directory itself to be excluded")
+	}
+	if !e.Excluded(filepath.Join(dir, "vendor", "pkg", "x.go")) {
+		t.Errorf("expected a file under the excluded directory to be excluded too")
+	}
+	if e.Excluded(filepath.Join(dir, "vendors", "x.go")) {
+		t.Errorf("vendor/ should not match the unrelated directory vendors")
+	}
+}
+
+func TestExcludeBasenameMatchesAnyComponent(t *testing.T) {
+	dir := t.TempDir()
+	e := &Exclude{}
+	if err := e.LoadFromFile(writeExcludeConfig(t, dir, `{".git": "vcs metadata"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !e.Excluded(filepath.Join(dir, ".git")) {
+		t.Errorf("expected the basename pattern to match the top-level .git directory")
+	}
+	if !e.Excluded(filepath.Join(dir, "vendor", "pkg", ".git", "HEAD")) {
+		t.Errorf("expected .git to match anywhere along the path, not just at the root")
+	}
+	if e.Excluded(filepath.Join(dir, "gitignore.go")) {
+		t.Errorf(".git should not match a file whose name merely contains \"git\"")
+	}
+}
+
+func TestExcludeWalkSkipsExcludedDirectories(t *testing.T) {
+	dir := t.TempDir()
+	e := &Exclude{}
+	if err := e.LoadFromFile(writeExcludeConfig(t, dir, `{"vendor/": "vendored code"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "vendor", "pkg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "vendor", "pkg", "x.go"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var visited []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if e.Excluded(path) {
+			if info.IsDir() {
+				return SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() && filepath.Base(path) != "exclude.json" {
+			visited = append(visited, filepath.Base(path))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(visited) != 1 || visited[0] != "main.go" {
+		t.Errorf("expected the walk to skip vendor/'s contents entirely, visited %v", visited)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFileName is the name of the per-directory ignore file discovered by
+// LoadFromTree in each directory of a tree.
+const ignoreFileName = ".ineffassignignore"
+
+// LoadFromTree walks root and builds an Exclude from every .ineffassignignore
+// file it finds. Patterns in a .ineffassignignore are scoped to the
+// directory that contains it and everything beneath it, the same way
+// .gitignore files nest: filepath.Walk visits a directory before its
+// descendants, so a deeper file's patterns are evaluated after its
+// ancestors' and a negation in it can re-include a path an ancestor
+// excluded.
+func LoadFromTree(root string) (*Exclude, error) {
+	e := &Exclude{}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		base, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		patterns, err := loadPatternFile(filepath.Join(path, ignoreFileName), base)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		e.patterns = append(e.patterns, patterns...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// RegisterTreeFlag registers the flag that enables .ineffassignignore
+// discovery: when the returned bool is true after fs.Parse, the caller
+// should call LoadFromTree(root) alongside any single JSON exclude file
+// and merge the two Excludes' patterns, as main does.
+func RegisterTreeFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("exclude-tree", false, "auto-discover .ineffassignignore files under the analyzed tree, in addition to -exclude-config")
+}
+
+// loadPatternFile reads a plain, newline-delimited pattern file and
+// compiles its patterns against base. Blank lines and lines starting
+// with "#" are ignored.
+func loadPatternFile(file, base string) ([]Pattern, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var patterns []Pattern
+	for _, line := range strings.Split(string(b), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		p, err := compilePattern(trimmed, "", base, false)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", file, err)
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeIgnoreFile(t *testing.T, dir, body string) {
+	t.Helper()
+	if err := ioutil.WriteFile(filepath.Join(dir, ignoreFileName), []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadFromTreeScopesToDirectory(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	other := filepath.Join(root, "other")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(other, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeIgnoreFile(t, sub, "*.go\n")
+
+	e, err := LoadFromTree(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !e.Excluded(filepath.Join(sub, "foo.go")) {
+		t.Errorf("expected sub/.ineffassignignore to exclude sub/foo.go")
+	}
+	if e.Excluded(filepath.Join(other, "foo.go")) {
+		t.Errorf("sub/.ineffassignignore should not reach into other/foo.go")
+	}
+}
+
+func TestLoadFromTreeNesting(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeIgnoreFile(t, root, "*.go\n")
+	writeIgnoreFile(t, sub, "!keep.go\n")
+
+	e, err := LoadFromTree(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !e.Excluded(filepath.Join(root, "top.go")) {
+		t.Errorf("expected the root ignore file to exclude top.go")
+	}
+	if !e.Excluded(filepath.Join(sub, "other.go")) {
+		t.Errorf("expected the root ignore file to reach into sub/other.go")
+	}
+	if e.Excluded(filepath.Join(sub, "keep.go")) {
+		t.Errorf("expected sub's negation to re-include sub/keep.go")
+	}
+}
+
+func TestRegisterTreeFlag(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	useTree := RegisterTreeFlag(fs)
+	if *useTree {
+		t.Fatal("expected -exclude-tree to default to false")
+	}
+	if err := fs.Parse([]string{"-exclude-tree"}); err != nil {
+		t.Fatal(err)
+	}
+	if !*useTree {
+		t.Errorf("expected -exclude-tree to be true after parsing")
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+)
+
+var _ json.Unmarshaler = (*Filter)(nil)
+
+// Filter pairs an include list with an exclude list: a path is accepted
+// iff it matches an include pattern (or Include is empty) and matches no
+// exclude pattern. Unlike Exclude, which can only subtract from the
+// analysis, a Filter lets callers positively scope it, e.g. to only
+// "internal/**".
+//
+// As json, this is a bare pattern-to-comment object, treated as an
+// exclude-only filter:
+//  {"testdata/**": "generated, never worth fixing"}
+// or an object with separate include and exclude pattern lists:
+//  {"include": ["internal/**"], "exclude": ["internal/testdata/**"]}
+type Filter struct {
+	Include []Pattern
+	Exclude []Pattern
+}
+
+// Match reports whether path is accepted by f: it must match at least one
+// pattern in Include (or Include must be empty) and must match no pattern
+// in Exclude.
+func (f *Filter) Match(path string) bool {
+	if len(f.Include) > 0 {
+		included := false
+		for _, p := range f.Include {
+			if p.matches(path) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+	}
+	for _, p := range f.Exclude {
+		if p.matches(path) {
+			return false
+		}
+	}
+	return true
+}
+
+// UnmarshalJSON accepts either a bare pattern-to-comment object, which is
+// treated as an exclude-only filter (matching the historical Exclude
+// behavior), or an object with separate "include" and "exclude" pattern
+// lists. The two are told apart by shape, not by which keys are present:
+// the bare form's values are comment strings, so a legacy config that
+// happens to exclude a file literally named "include" or "exclude" still
+// decodes as a flat exclude list instead of being misread as the
+// structured form.
+func (f *Filter) UnmarshalJSON(b []byte) error {
+	base, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	var flat map[string]string
+	if err := json.Unmarshal(b, &flat); err == nil {
+		keys, vals, err := decodeOrderedStringMap(b)
+		if err != nil {
+			return err
+		}
+		patterns := make([]Pattern, 0, len(keys))
+		for i, raw := range keys {
+			p, err := compilePattern(raw, vals[i], base, false)
+			if err != nil {
+				return err
+			}
+			patterns = append(patterns, p)
+		}
+		f.Include = nil
+		f.Exclude = patterns
+		return nil
+	}
+
+	var obj struct {
+		Include []string `json:"include"`
+		Exclude []string `json:"exclude"`
+	}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return err
+	}
+	include, err := compilePatternList(obj.Include, base)
+	if err != nil {
+		return err
+	}
+	exclude, err := compilePatternList(obj.Exclude, base)
+	if err != nil {
+		return err
+	}
+	f.Include = include
+	f.Exclude = exclude
+	return nil
+}
+
+var _ flag.Value = (*patternFlag)(nil)
+
+// RegisterFlags registers --include and --exclude flags on fs, as used by
+// main: each flag may be repeated, and the returned Filter is populated
+// once fs.Parse has run.
+func RegisterFlags(fs *flag.FlagSet) *Filter {
+	f := &Filter{}
+	fs.Var((*patternFlag)(&f.Include), "include", "only analyze paths matching this pattern (may be repeated)")
+	fs.Var((*patternFlag)(&f.Exclude), "exclude", "skip paths matching this pattern (may be repeated)")
+	return f
+}
+
+// patternFlag adapts a []Pattern to flag.Value, compiling each flag
+// occurrence against the process's working directory as it is parsed.
+type patternFlag []Pattern
+
+func (pf *patternFlag) String() string {
+	return ""
+}
+
+func (pf *patternFlag) Set(raw string) error {
+	base, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	p, err := compilePattern(raw, "", base, false)
+	if err != nil {
+		return err
+	}
+	*pf = append(*pf, p)
+	return nil
+}
+
+// compilePatternList compiles every bare pattern in list against base.
+// Filter's include/exclude lists carry no per-pattern comment and, having
+// no negation, are not order-sensitive.
+func compilePatternList(list []string, base string) ([]Pattern, error) {
+	patterns := make([]Pattern, 0, len(list))
+	for _, raw := range list {
+		p, err := compilePattern(raw, "", base, false)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
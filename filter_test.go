@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFilterMatchIncludeAndExclude(t *testing.T) {
+	base, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	include, err := compilePatternList([]string{"internal/**"}, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exclude, err := compilePatternList([]string{"internal/testdata/**"}, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &Filter{Include: include, Exclude: exclude}
+
+	if !f.Match(filepath.Join(base, "internal", "pkg", "file.go")) {
+		t.Errorf("expected a path under internal/ to be accepted")
+	}
+	if f.Match(filepath.Join(base, "internal", "testdata", "file.go")) {
+		t.Errorf("expected internal/testdata/** to be excluded despite matching Include")
+	}
+	if f.Match(filepath.Join(base, "cmd", "file.go")) {
+		t.Errorf("expected a path outside Include to be rejected")
+	}
+}
+
+func TestFilterMatchEmptyIncludeAcceptsEverything(t *testing.T) {
+	f := &Filter{}
+	if !f.Match("anything.go") {
+		t.Errorf("expected an empty Include to accept any path not excluded")
+	}
+}
+
+func TestFilterUnmarshalJSONBareForm(t *testing.T) {
+	var f Filter
+	if err := json.Unmarshal([]byte(`{"testdata/**": "generated, never worth fixing"}`), &f); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Include) != 0 {
+		t.Errorf("expected the bare form to leave Include empty")
+	}
+	if len(f.Exclude) != 1 {
+		t.Errorf("expected the bare form to populate Exclude with one pattern, got %d", len(f.Exclude))
+	}
+}
+
+func TestFilterUnmarshalJSONStructuredForm(t *testing.T) {
+	var f Filter
+	body := `{"include": ["internal/**"], "exclude": ["internal/testdata/**"]}`
+	if err := json.Unmarshal([]byte(body), &f); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Include) != 1 || len(f.Exclude) != 1 {
+		t.Errorf("expected one include and one exclude pattern, got %d/%d", len(f.Include), len(f.Exclude))
+	}
+}
+
+func TestFilterUnmarshalJSONBareFormWithIncludeExcludeNames(t *testing.T) {
+	var f Filter
+	body := `{"include": "literally a file named include", "exclude": "literally a file named exclude"}`
+	if err := json.Unmarshal([]byte(body), &f); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Include) != 0 {
+		t.Errorf("expected a legacy config excluding files named include/exclude to leave Include empty")
+	}
+	if len(f.Exclude) != 2 {
+		t.Errorf("expected both literal patterns to land in Exclude, got %d", len(f.Exclude))
+	}
+}
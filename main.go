@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	excludeFile := fs.String("exclude-config", "", "json exclude configuration file")
+	useTree := RegisterTreeFlag(fs)
+	filter := RegisterFlags(fs)
+	fs.Parse(os.Args[1:])
+
+	roots := fs.Args()
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+
+	exclude := &Exclude{}
+	if *excludeFile != "" {
+		if err := exclude.LoadFromFile(*excludeFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	for _, root := range roots {
+		if *useTree {
+			treeExclude, err := LoadFromTree(root)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			exclude.patterns = append(exclude.patterns, treeExclude.patterns...)
+		}
+
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if exclude.Excluded(path) {
+				if info.IsDir() {
+					return SkipDir
+				}
+				return nil
+			}
+			if info.IsDir() || !filter.Match(path) {
+				return nil
+			}
+			fmt.Println(path)
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}